@@ -1,103 +1,179 @@
 package handlers
 
 import (
-	"encoding/csv"
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
+	"log/slog"
+	"net/http"
 
 	"github.com/momentohq/aws-usage-analyzer/internal/metrics"
 	"github.com/momentohq/aws-usage-analyzer/internal/resources"
-	"github.com/momentohq/aws-usage-analyzer/internal/util"
+	"github.com/momentohq/aws-usage-analyzer/internal/sink"
+	"github.com/momentohq/aws-usage-analyzer/internal/stats"
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/memorydb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Metric collection backends for Handler.Source.
+const (
+	SourceAPI    = "api"
+	SourceStream = "stream"
+)
+
+// Output sink names for Handler.Outputs.
+const (
+	OutputCSV         = "csv"
+	OutputNDJSON      = "ndjson"
+	OutputParquetS3   = "parquet-s3"
+	OutputRemoteWrite = "remote-write"
 )
 
 type Handler struct {
-	Cw  *cloudwatch.Client
-	Ec  *elasticache.Client
-	DDB *dynamodb.Client
+	Cw       *cloudwatch.Client
+	Ec       *elasticache.Client
+	DDB      *dynamodb.Client
+	MemoryDb *memorydb.Client
+	S3       *s3.Client
+
+	// Source selects how resource metrics are collected: SourceAPI (default)
+	// polls CloudWatch GetMetricData, batching queries across resources that
+	// share a namespace; SourceStream instead reads
+	// CloudWatch Metric Streams output that a Firehose has delivered to
+	// StreamBucket/StreamPrefix, avoiding the GetMetricData TPS limit.
+	Source       string
+	StreamBucket string
+	StreamPrefix string
+
+	// StatsAddr, if set, serves the stats.Report() Prometheus text output at
+	// GET /metrics so operators can see where collection time is going on
+	// very large accounts instead of just watching the progress bar.
+	StatsAddr string
+
+	// MaxConcurrency and RateLimitTPS tune the SourceAPI collection
+	// backend's worker pool and GetMetricData rate limiter. Zero means let
+	// metrics.ResourceMetricFetcher pick its env/default values.
+	MaxConcurrency int
+	RateLimitTPS   int
+
+	// Logger is used for all structured collection logging. Defaults to
+	// slog.Default() when unset.
+	Logger *slog.Logger
+
+	// Outputs selects which sink.Sink implementations results are written
+	// to once collection finishes. Defaults to []string{OutputCSV}.
+	Outputs []string
+	// ParquetS3URI is the destination object for OutputParquetS3, e.g.
+	// "s3://my-bucket/usage/run.parquet".
+	ParquetS3URI string
+	// RemoteWriteURL is the Prometheus remote_write endpoint for
+	// OutputRemoteWrite.
+	RemoteWriteURL string
+}
+
+func (h *Handler) logger() *slog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return slog.Default()
 }
 
 func (h *Handler) Handle() error {
-	util.MultiPrintln([]string{
-		"Starting AWS Usage Collector",
-		"-----------------------------------",
-	})
+	if h.StatsAddr != "" {
+		h.serveStats()
+	}
+
+	h.logger().Info("starting AWS usage collector")
 
-	fmt.Println("Looking for Elasticache Nodes to analyze:")
-	ec := &resources.Elasticache{Client: h.Ec}
-	elasticacheNodes, err := ec.GetAll()
-	if err != nil {
-		return err
+	collectors := []resources.Resource{
+		&resources.Elasticache{Client: h.Ec, Logger: h.Logger},
+		&resources.ElasticacheServerless{Client: h.Ec, Logger: h.Logger},
+		&resources.MemoryDb{Client: h.MemoryDb, Logger: h.Logger},
+		// DynamoDbStreams is folded into DynamoDb.GetAll via Streams rather
+		// than run as its own collector, so the two share one
+		// ListTables+DescribeTable pass instead of each walking every table.
+		&resources.DynamoDb{Client: h.DDB, Logger: h.Logger, Streams: &resources.DynamoDbStreams{Client: h.DDB, Logger: h.Logger}},
 	}
-	fmt.Printf("Found %d cache nodes to collect data on.\n\n", len(elasticacheNodes))
 
-	fmt.Println("Looking for DDB tables to analyze:")
-	ddb := &resources.DynamoDb{Client: h.DDB}
-	ddbTables, err := ddb.GetAll()
-	if err != nil {
-		return err
+	var resourcesToFetchMetricsOn []*resources.ResourceSummary
+	for _, c := range collectors {
+		found, err := c.GetAll()
+		if err != nil {
+			return err
+		}
+		h.logger().Info("found resources to collect data on", "collector", fmt.Sprintf("%T", c), "count", len(found))
+		resourcesToFetchMetricsOn = append(resourcesToFetchMetricsOn, found...)
 	}
-	fmt.Printf("Found %d ddb tables to collect data on.\n\n", len(ddbTables))
-
-	util.MultiPrintln([]string{
-		"Starting metrics collection on resources",
-		"Note: Please be patient if this is going slowly",
-		"Can increase account limits in AWS Console for ",
-		"CloudWatch GetMetrics API.\n\n",
-		"Collecting Data on Resources:",
-	})
 
-	resourcesToFetchMetricsOn := append(ddbTables, elasticacheNodes...)
+	h.logger().Info("starting metrics collection on resources; can increase account limits in the AWS console for CloudWatch GetMetrics if this is going slowly")
 
-	metricFetcher := metrics.ResourceMetricFetcher{CW: h.Cw}
-	metricFetcher.GetMetricsForResources(resourcesToFetchMetricsOn)
-	// Write out resource data to csv
-	err = writeOutResults(resourcesToFetchMetricsOn)
-	if err != nil {
-		return err
+	if h.Source == SourceStream {
+		streamFetcher := metrics.StreamMetricFetcher{S3: h.S3, Bucket: h.StreamBucket, Prefix: h.StreamPrefix}
+		if err := streamFetcher.GetMetricsForResources(resourcesToFetchMetricsOn); err != nil {
+			return err
+		}
+	} else {
+		metricFetcher := metrics.ResourceMetricFetcher{
+			CW:             h.Cw,
+			MaxConcurrency: h.MaxConcurrency,
+			RateLimitTPS:   h.RateLimitTPS,
+			Logger:         h.Logger,
+		}
+		metricFetcher.GetMetricsForResources(resourcesToFetchMetricsOn)
+	}
+	for _, s := range h.sinks() {
+		if err := s.Write(context.TODO(), resourcesToFetchMetricsOn); err != nil {
+			return err
+		}
 	}
 
-	fmt.Println("")
-	fmt.Println("Finished collecting data! Please send results.csv to Momento for analysis")
+	h.logger().Info("finished collecting data! please send results.csv to Momento for analysis")
 
 	return nil
 }
 
-func writeOutResults(results []*resources.ResourceSummary) error {
-	f, err := os.Create("./results.csv")
-	if err != nil {
-		return err
+// sinks builds the sink.Sink implementations selected by h.Outputs,
+// defaulting to CSVSink (the original results.csv + errors.json format)
+// when none are set.
+func (h *Handler) sinks() []sink.Sink {
+	outputs := h.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{OutputCSV}
 	}
 
-	writer := csv.NewWriter(f)
-
-	data := [][]string{
-		{"ResourceId", "Type", "AdditionalData", "Metrics"},
-	}
-	for _, r := range results {
-		metrics, err := json.Marshal(r.Metrics)
-		if err != nil {
-			return err
+	sinks := make([]sink.Sink, 0, len(outputs))
+	for _, o := range outputs {
+		switch o {
+		case OutputCSV:
+			sinks = append(sinks, &sink.CSVSink{})
+		case OutputNDJSON:
+			sinks = append(sinks, &sink.NDJSONSink{})
+		case OutputParquetS3:
+			sinks = append(sinks, &sink.ParquetS3Sink{S3: h.S3, URI: h.ParquetS3URI})
+		case OutputRemoteWrite:
+			sinks = append(sinks, &sink.RemoteWriteSink{Endpoint: h.RemoteWriteURL})
+		default:
+			h.logger().Warn("unknown output sink, skipping", "output", o)
 		}
-		additionalData, err := json.Marshal(r.AdditionalData)
-		if err != nil {
-			return err
-		}
-		data = append(data, []string{
-			r.ID,
-			r.Type,
-			string(additionalData),
-			string(metrics),
-		})
 	}
+	return sinks
+}
 
-	err = writer.WriteAll(data)
-	if err != nil {
-		return err
-	}
-	return nil
+// serveStats starts a background HTTP server exposing self-telemetry
+// (collector latency histograms, call counters, throttle counters) in
+// Prometheus text format so the analyzer can be scraped while it runs.
+func (h *Handler) serveStats() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, stats.Report())
+	})
+	go func() {
+		if err := http.ListenAndServe(h.StatsAddr, mux); err != nil {
+			h.logger().Error("stats server stopped", "err", err)
+		}
+	}()
 }
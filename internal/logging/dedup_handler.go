@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// dedupWindow is how long a repeated (level, message) pair is suppressed
+// for before it's allowed through again.
+const dedupWindow = 30 * time.Second
+
+// DedupHandler wraps a slog.Handler and drops records that repeat a
+// recently-seen (level, message) pair, so that e.g. a CloudWatch throttling
+// error hit by hundreds of resources in the same batch doesn't flood
+// stdout. The first occurrence always passes through; later occurrences in
+// the same window are counted and folded into a "suppressed_repeats" attr
+// the next time that record is allowed through.
+type DedupHandler struct {
+	next slog.Handler
+
+	mu   sync.Mutex
+	seen map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	lastSeen   time.Time
+	suppressed int
+}
+
+// NewDedupHandler wraps next with duplicate suppression.
+func NewDedupHandler(next slog.Handler) *DedupHandler {
+	return &DedupHandler{next: next, seen: map[string]*dedupEntry{}}
+}
+
+func (h *DedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *DedupHandler) Handle(ctx context.Context, record slog.Record) error {
+	key := record.Level.String() + "|" + record.Message
+
+	h.mu.Lock()
+	entry, exists := h.seen[key]
+	now := time.Now()
+	if exists && now.Sub(entry.lastSeen) < dedupWindow {
+		entry.suppressed++
+		h.mu.Unlock()
+		return nil
+	}
+	suppressed := 0
+	if exists {
+		suppressed = entry.suppressed
+	}
+	h.seen[key] = &dedupEntry{lastSeen: now}
+	h.mu.Unlock()
+
+	if suppressed > 0 {
+		record = record.Clone()
+		record.AddAttrs(slog.Int("suppressed_repeats", suppressed))
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &DedupHandler{next: h.next.WithAttrs(attrs), seen: h.seen}
+}
+
+func (h *DedupHandler) WithGroup(name string) slog.Handler {
+	return &DedupHandler{next: h.next.WithGroup(name), seen: h.seen}
+}
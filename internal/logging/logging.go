@@ -0,0 +1,21 @@
+// Package logging builds the analyzer's *slog.Logger: a JSON or text handler
+// depending on --log-format, wrapped so that repeated identical log lines
+// (a throttling error fired once per resource, say) don't flood the output.
+package logging
+
+import (
+	"log/slog"
+	"os"
+)
+
+// New returns a logger writing to stdout in the given format ("json" or
+// "text", defaulting to "text" for anything else).
+func New(format string) *slog.Logger {
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, nil)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, nil)
+	}
+	return slog.New(NewDedupHandler(handler))
+}
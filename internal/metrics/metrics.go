@@ -2,100 +2,325 @@ package metrics
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/smithy-go"
 	"github.com/cheggaaa/pb/v3"
 	"github.com/momentohq/aws-usage-analyzer/internal/resources"
-	"log"
+	"github.com/momentohq/aws-usage-analyzer/internal/stats"
+	"log/slog"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const MaxConcurrency = 3
+const (
+	// DefaultMaxConcurrency is how many GetMetricData batches are in flight
+	// at once when ResourceMetricFetcher.MaxConcurrency is unset.
+	DefaultMaxConcurrency = 3
+	// DefaultRateLimitTPS matches the CloudWatch GetMetricData per-account
+	// TPS limit when ResourceMetricFetcher.RateLimitTPS is unset.
+	DefaultRateLimitTPS = 50
+	// maxQueriesPerBatch is the CloudWatch GetMetricData limit on
+	// MetricDataQuery entries per call.
+	maxQueriesPerBatch = 500
 
+	maxConcurrencyEnvVar = "AWS_USAGE_ANALYZER_MAX_CONCURRENCY"
+	rateLimitTPSEnvVar   = "AWS_USAGE_ANALYZER_RATE_LIMIT_TPS"
+)
+
+var (
+	getMetricDataLatency   = stats.NewLatencyHistogram15s32("cw.get_metric_data.latency")
+	getMetricDataCalls     = stats.NewCounter32("cw.get_metric_data.calls")
+	getMetricDataThrottled = stats.NewCounter32("cw.get_metric_data.throttled")
+	getMetricDataInFlight  = stats.NewMeter32("cw.get_metric_data.in_flight_batches")
+	fetchBatchLatency      = stats.NewLatencyHistogram15s32("fetcher.fetch_batch.latency")
+
+	inFlightBatches int32
+)
+
+// CloudWatchGetMetricDataAPI is the subset of *cloudwatch.Client that
+// ResourceMetricFetcher depends on, so tests (and the benchmark in
+// metrics_bench_test.go) can
+// supply a fake instead of talking to real CloudWatch.
+type CloudWatchGetMetricDataAPI interface {
+	GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error)
+}
+
+// ResourceMetricFetcher pulls the last 30 days of CloudWatch metrics for a
+// set of resources. MaxConcurrency and RateLimitTPS are optional; a zero
+// value falls back to the AWS_USAGE_ANALYZER_MAX_CONCURRENCY /
+// AWS_USAGE_ANALYZER_RATE_LIMIT_TPS env vars, then to the package defaults.
 type ResourceMetricFetcher struct {
-	CW *cloudwatch.Client
+	CW             CloudWatchGetMetricDataAPI
+	MaxConcurrency int
+	RateLimitTPS   int
+	Logger         *slog.Logger
+
+	limiterOnce sync.Once
+	limiter     *tokenBucket
+}
+
+func (c *ResourceMetricFetcher) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// queryRef traces a single MetricDataQuery back to the resource and metric
+// name it was built for, so a batched GetMetricData response spanning
+// several resources can be demultiplexed.
+type queryRef struct {
+	resource   *resources.ResourceSummary
+	metricName string
+}
+
+// batch is up to maxQueriesPerBatch MetricDataQuery entries sharing a
+// namespace, plus enough bookkeeping to route the results back to the
+// resources that asked for them.
+type batch struct {
+	queries   []types.MetricDataQuery
+	refs      map[string]queryRef
+	resources []*resources.ResourceSummary
+}
+
+func (c *ResourceMetricFetcher) concurrency() int {
+	if c.MaxConcurrency > 0 {
+		return c.MaxConcurrency
+	}
+	return envIntOrDefault(maxConcurrencyEnvVar, DefaultMaxConcurrency)
 }
 
-func (c *ResourceMetricFetcher) GetMetricsForResources(resources []*resources.ResourceSummary) {
-	// Init progress bar to show user feedback on script progress
-	bar := pb.Simple.Start(len(resources))
+func (c *ResourceMetricFetcher) rateLimiter() *tokenBucket {
+	c.limiterOnce.Do(func() {
+		tps := c.RateLimitTPS
+		if tps <= 0 {
+			tps = envIntOrDefault(rateLimitTPSEnvVar, DefaultRateLimitTPS)
+		}
+		c.limiter = newTokenBucket(tps)
+	})
+	return c.limiter
+}
 
-	// Wait group and concurrency guard to grab node monitoring data with controlled concurrency
-	var wg = &sync.WaitGroup{}
-	guard := make(chan struct{}, MaxConcurrency) // Guard to limit max concurrency
-	wg.Add(len(resources))
+func envIntOrDefault(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return def
+}
 
-	for _, resource := range resources {
-		guard <- struct{}{} // Limits max concurrency to maxGoroutines
-		go c.fetchMetricsForResource(resource)
-		wg.Done()
-		bar.Increment()
-		<-guard
+// GetMetricsForResources fetches metrics for every resource, batching
+// MetricDataQuery entries across resources that share a namespace (up to
+// the GetMetricData 500-entries-per-call limit) and running up to
+// concurrency() batches at once through a rate limiter sized to the
+// GetMetricData TPS limit.
+func (c *ResourceMetricFetcher) GetMetricsForResources(resourceList []*resources.ResourceSummary) {
+	batches := buildBatches(resourceList)
+
+	bar := pb.Simple.Start(len(batches))
+
+	var wg sync.WaitGroup
+	guard := make(chan struct{}, c.concurrency())
+	wg.Add(len(batches))
+
+	for _, b := range batches {
+		guard <- struct{}{} // limits in-flight batches to concurrency()
+		go func(b *batch) {
+			defer wg.Done()
+			defer func() { <-guard }()
+			defer bar.Increment()
+			c.fetchBatch(b)
+		}(b)
 	}
 
-	// Block on tasks finishing
 	wg.Wait()
-	bar.Finish() // complete loading bar for user
-}
-
-func (c *ResourceMetricFetcher) fetchMetricsForResource(
-	resource *resources.ResourceSummary,
-) {
-	targets := resource.Resource.GetMetricTargets(resource)
-	for statType, metrics := range targets.Targets {
-		var metricsToGrab []types.MetricDataQuery
-		for _, metric := range metrics {
-			metricsToGrab = append(metricsToGrab, types.MetricDataQuery{
-				MetricStat: &types.MetricStat{
-					Metric: &types.Metric{
-						MetricName: aws.String(metric),
-						Namespace:  aws.String(targets.Namespace),
-						Dimensions: targets.Dimensions,
+	bar.Finish()
+
+	// The rate limiter's refill goroutine otherwise runs for the process
+	// lifetime; it's only needed while this collection run is in flight.
+	if c.limiter != nil {
+		c.limiter.Close()
+	}
+}
+
+// resourceQueries is one resource's full set of MetricDataQuery entries.
+// Keeping them together when chunking into batches means a resource's
+// results/errors are only ever written from a single goroutine.
+type resourceQueries struct {
+	namespace string
+	resource  *resources.ResourceSummary
+	queries   []types.MetricDataQuery
+	refs      map[string]queryRef
+}
+
+// buildBatches groups every (resource, statType, metric) query by
+// namespace, then packs each namespace's per-resource query groups into
+// batches of at most maxQueriesPerBatch entries without splitting a single
+// resource's queries across two batches.
+func buildBatches(resourceList []*resources.ResourceSummary) []*batch {
+	byNamespace := map[string][]resourceQueries{}
+
+	idSeq := 0
+	for _, resource := range resourceList {
+		targets := resource.Resource.GetMetricTargets(resource)
+		rq := resourceQueries{namespace: targets.Namespace, resource: resource, refs: map[string]queryRef{}}
+
+		for statType, metricNames := range targets.Targets {
+			for _, metricName := range metricNames {
+				id := fmt.Sprintf("q%d", idSeq)
+				idSeq++
+
+				rq.queries = append(rq.queries, types.MetricDataQuery{
+					MetricStat: &types.MetricStat{
+						Metric: &types.Metric{
+							MetricName: aws.String(metricName),
+							Namespace:  aws.String(targets.Namespace),
+							Dimensions: targets.Dimensions,
+						},
+						Period: aws.Int32(60 * 60 * 24), // 1 day interval
+						Stat:   aws.String(statType),
 					},
-					Period: aws.Int32(60 * 60 * 24), // 1 day interval
-					Stat:   aws.String(statType),
-				},
-				Id: aws.String(strings.ToLower(metric)),
-			})
+					Id: aws.String(id),
+				})
+				rq.refs[id] = queryRef{resource: resource, metricName: strings.ToLower(metricName)}
+			}
 		}
-		startTime := aws.Time(time.Now().Add(time.Duration(-30) * 24 * time.Hour)) // 30 Days ago
-		endTime := aws.Time(time.Now())
+		byNamespace[targets.Namespace] = append(byNamespace[targets.Namespace], rq)
+	}
+
+	var batches []*batch
+	for _, resourceGroups := range byNamespace {
+		current := &batch{refs: map[string]queryRef{}}
+		for _, rq := range resourceGroups {
+			if len(current.queries)+len(rq.queries) > maxQueriesPerBatch && len(current.queries) > 0 {
+				batches = append(batches, current)
+				current = &batch{refs: map[string]queryRef{}}
+			}
+			current.resources = append(current.resources, rq.resource)
+			current.queries = append(current.queries, rq.queries...)
+			for id, ref := range rq.refs {
+				current.refs[id] = ref
+			}
+		}
+		if len(current.queries) > 0 {
+			batches = append(batches, current)
+		}
+	}
+	return batches
+}
 
-		data, err := c.CW.GetMetricData(context.TODO(), &cloudwatch.GetMetricDataInput{
+func (c *ResourceMetricFetcher) fetchBatch(b *batch) {
+	start := time.Now()
+	defer func() { fetchBatchLatency.Value(time.Since(start)) }()
+
+	getMetricDataInFlight.Set(uint32(atomic.AddInt32(&inFlightBatches, 1)))
+	defer func() { getMetricDataInFlight.Set(uint32(atomic.AddInt32(&inFlightBatches, -1))) }()
+
+	startTime := aws.Time(time.Now().Add(time.Duration(-30) * 24 * time.Hour)) // 30 Days ago
+	endTime := aws.Time(time.Now())
+
+	data, err := c.getMetricData(&cloudwatch.GetMetricDataInput{
+		EndTime:           endTime,
+		MetricDataQueries: b.queries,
+		StartTime:         startTime,
+	})
+	if err != nil {
+		c.recordBatchError(b, "get_metric_data", err)
+		return
+	}
+
+	for {
+		c.applyResults(data.MetricDataResults, b.refs)
+		if data.NextToken == nil {
+			break
+		}
+		data, err = c.getMetricData(&cloudwatch.GetMetricDataInput{
 			EndTime:           endTime,
-			MetricDataQueries: metricsToGrab,
+			MetricDataQueries: b.queries,
 			StartTime:         startTime,
+			NextToken:         data.NextToken,
 		})
 		if err != nil {
-			log.Printf("error grabbing cw data err=%+v\n", err)
+			c.recordBatchError(b, "get_metric_data_paginate", err)
 			return
 		}
+	}
+}
 
-		for {
-			for _, metric := range data.MetricDataResults {
-				resource.Metrics = append(resource.Metrics, resources.MetricBlob{
-					Name:   *metric.Id,
-					Values: metric.Values,
-				})
-			}
-			if data.NextToken != nil {
-				data, err = c.CW.GetMetricData(context.TODO(), &cloudwatch.GetMetricDataInput{
-					EndTime:           endTime,
-					MetricDataQueries: metricsToGrab,
-					StartTime:         startTime,
-					NextToken:         data.NextToken,
-				})
-				if err != nil {
-					log.Printf("here error grabbing cw data err=%+v\n", err)
-					return
-				}
-			} else {
-				break
-			}
+// recordBatchError logs a batch-level GetMetricData failure once and
+// attaches a CollectionError to every resource the batch was fetching for,
+// so the failure is visible per-resource in results.csv/errors.json instead
+// of only in the logs.
+func (c *ResourceMetricFetcher) recordBatchError(b *batch, stage string, err error) {
+	c.logger().Error("error grabbing cw data", "stage", stage, "err", err, "resources", len(b.resources))
+
+	collErr := resources.CollectionError{
+		Stage:     stage,
+		Error:     err.Error(),
+		RequestID: requestIDFromErr(err),
+	}
+	for _, resource := range b.resources {
+		resource.Errors = append(resource.Errors, collErr)
+	}
+}
+
+// requestIDFromErr pulls the upstream AWS request ID out of err, when the
+// SDK error carries one, so operators can hand CloudWatch support a request
+// ID instead of just a throttling message.
+func requestIDFromErr(err error) string {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.ServiceRequestID()
+	}
+	return ""
+}
+
+func (c *ResourceMetricFetcher) applyResults(results []types.MetricDataResult, refs map[string]queryRef) {
+	for _, metric := range results {
+		ref, ok := refs[*metric.Id]
+		if !ok {
+			continue
+		}
+		timestamps := make([]int64, len(metric.Timestamps))
+		for i, ts := range metric.Timestamps {
+			timestamps[i] = ts.UnixMilli()
 		}
+		ref.resource.Metrics = append(ref.resource.Metrics, resources.MetricBlob{
+			Name:       ref.metricName,
+			Values:     metric.Values,
+			Timestamps: timestamps,
+		})
+	}
+}
+
+// getMetricData calls CloudWatch GetMetricData, recording call latency and
+// counting throttling errors so operators can see where collection time is
+// going via the --stats-addr endpoint, and blocking on the rate limiter so
+// concurrent batches don't exceed RateLimitTPS.
+func (c *ResourceMetricFetcher) getMetricData(input *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	ctx := context.TODO()
+	if err := c.rateLimiter().Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	getMetricDataCalls.Inc(1)
+	data, err := c.CW.GetMetricData(ctx, input)
+	getMetricDataLatency.Value(time.Since(start))
 
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ThrottlingException" {
+		getMetricDataThrottled.Inc(1)
 	}
+	return data, err
 }
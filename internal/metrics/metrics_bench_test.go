@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/momentohq/aws-usage-analyzer/internal/resources"
+)
+
+// fakeDynamoDb satisfies resources.Resource with a fixed namespace/metric
+// set so BenchmarkGetMetricsForResources can build a realistic query shape
+// without a live AWS account.
+type fakeDynamoDb struct{}
+
+func (fakeDynamoDb) GetAll() ([]*resources.ResourceSummary, error) { return nil, nil }
+
+func (fakeDynamoDb) GetMetricTargets(r *resources.ResourceSummary) resources.ResourceMetricTargets {
+	return resources.ResourceMetricTargets{
+		Namespace: "AWS/DynamoDB",
+		Dimensions: []types.Dimension{
+			{Name: aws.String("TableName"), Value: aws.String(r.ID)},
+		},
+		Targets: map[string][]string{
+			"Sum": {"ConsumedReadCapacityUnits", "ConsumedWriteCapacityUnits"},
+		},
+	}
+}
+
+// fakeCloudWatch returns an empty successful response for every call,
+// counting how many GetMetricData calls it received. calls is accessed
+// concurrently by the worker pool's goroutines, so it's an atomic.Int64
+// rather than a plain int.
+type fakeCloudWatch struct {
+	calls atomic.Int64
+}
+
+func (f *fakeCloudWatch) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	f.calls.Add(1)
+	return &cloudwatch.GetMetricDataOutput{}, nil
+}
+
+func syntheticResources(n int) []*resources.ResourceSummary {
+	ddb := fakeDynamoDb{}
+	resourceList := make([]*resources.ResourceSummary, n)
+	for i := 0; i < n; i++ {
+		resourceList[i] = &resources.ResourceSummary{
+			ID:       fmt.Sprintf("table-%d", i),
+			Type:     resources.AwsDynamoDbTable,
+			Resource: ddb,
+		}
+	}
+	return resourceList
+}
+
+// BenchmarkGetMetricsForResources exercises a synthetic 1000-resource
+// inventory end to end. Before the worker-pool/batching fix this made one
+// GetMetricData call per resource; batching across a shared namespace
+// collapses that down to ceil(total queries / 500) calls.
+func BenchmarkGetMetricsForResources(b *testing.B) {
+	resourceList := syntheticResources(1000)
+
+	for i := 0; i < b.N; i++ {
+		cw := &fakeCloudWatch{}
+		fetcher := &ResourceMetricFetcher{CW: cw, MaxConcurrency: 8, RateLimitTPS: 1000}
+		fetcher.GetMetricsForResources(resourceList)
+		b.ReportMetric(float64(cw.calls.Load()), "cw-calls")
+	}
+}
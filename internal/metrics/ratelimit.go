@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// tokenBucket is a minimal token-bucket rate limiter used to keep
+// GetMetricData calls under the CloudWatch per-account TPS limit. It
+// refills one token every 1/tps, up to a burst of tps tokens, which is
+// enough smoothing for a worker pool issuing batched calls.
+type tokenBucket struct {
+	tokens chan struct{}
+	stop   chan struct{}
+}
+
+// newTokenBucket starts a token bucket that allows up to tps operations per
+// second, with a burst equal to tps.
+func newTokenBucket(tps int) *tokenBucket {
+	if tps <= 0 {
+		tps = 1
+	}
+	tb := &tokenBucket{
+		tokens: make(chan struct{}, tps),
+		stop:   make(chan struct{}),
+	}
+	for i := 0; i < tps; i++ {
+		tb.tokens <- struct{}{}
+	}
+
+	interval := time.Second / time.Duration(tps)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case tb.tokens <- struct{}{}:
+				default:
+				}
+			case <-tb.stop:
+				return
+			}
+		}
+	}()
+
+	return tb
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (tb *tokenBucket) Wait(ctx context.Context) error {
+	select {
+	case <-tb.tokens:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the bucket's refill goroutine.
+func (tb *tokenBucket) Close() {
+	close(tb.stop)
+}
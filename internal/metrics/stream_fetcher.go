@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/momentohq/aws-usage-analyzer/internal/resources"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// streamRecord mirrors a single line of a CloudWatch Metric Stream delivered
+// in the JSON output format via Kinesis Firehose:
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/metric-streams-formats-json.html
+type streamRecord struct {
+	MetricStreamName string            `json:"metric_stream_name"`
+	Namespace        string            `json:"namespace"`
+	MetricName       string            `json:"metric_name"`
+	Dimensions       map[string]string `json:"dimensions"`
+	Timestamp        int64             `json:"timestamp"`
+	Value            struct {
+		Max   float64 `json:"max"`
+		Min   float64 `json:"min"`
+		Sum   float64 `json:"sum"`
+		Count float64 `json:"count"`
+	} `json:"value"`
+	Unit string `json:"unit"`
+}
+
+// StreamMetricFetcher reads CloudWatch Metric Streams output that a Kinesis
+// Firehose has delivered to S3 and joins it against a resource inventory,
+// as an alternative to polling GetMetricData directly. This trades the
+// GetMetricData TPS limit for S3 ListObjects/GetObject calls, which is a
+// better fit for accounts with hundreds of DynamoDB tables/GSIs and
+// ElastiCache nodes.
+type StreamMetricFetcher struct {
+	S3     *s3.Client
+	Bucket string
+	Prefix string
+}
+
+// GetMetricsForResources lists the last 30 days of Firehose-delivered
+// objects under Bucket/Prefix, parses each newline-delimited JSON record,
+// and appends matching metrics onto the resources whose dimensions match a
+// record's namespace/dimension set.
+func (c *StreamMetricFetcher) GetMetricsForResources(resourceList []*resources.ResourceSummary) error {
+	byKey := make(map[string]*resources.ResourceSummary, len(resourceList))
+	for _, r := range resourceList {
+		targets := r.Resource.GetMetricTargets(r)
+		byKey[streamJoinKeyFromCW(targets.Namespace, targets.Dimensions)] = r
+	}
+
+	cutoff := aws.Time(time.Now().Add(time.Duration(-30) * 24 * time.Hour))
+	acc := metricAccumulator{}
+
+	var continuationToken *string
+	for {
+		listRsp, err := c.S3.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+			Bucket:            aws.String(c.Bucket),
+			Prefix:            aws.String(c.Prefix),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return fmt.Errorf("listing metric stream objects: %w", err)
+		}
+
+		for _, obj := range listRsp.Contents {
+			if obj.LastModified != nil && obj.LastModified.Before(*cutoff) {
+				continue
+			}
+			if err := c.joinObject(*obj.Key, byKey, acc); err != nil {
+				return fmt.Errorf("reading metric stream object %s: %w", *obj.Key, err)
+			}
+		}
+
+		if listRsp.IsTruncated == nil || !*listRsp.IsTruncated {
+			break
+		}
+		continuationToken = listRsp.NextContinuationToken
+	}
+
+	acc.flush()
+	return nil
+}
+
+func (c *StreamMetricFetcher) joinObject(key string, byKey map[string]*resources.ResourceSummary, acc metricAccumulator) error {
+	getRsp, err := c.S3.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	defer getRsp.Body.Close()
+
+	scanner := bufio.NewScanner(getRsp.Body)
+	// Metric stream records routinely exceed bufio's 64KB default token size
+	// once a dimension set grows large, so give the scanner more headroom.
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec streamRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+
+		resource, ok := byKey[streamJoinKeyFromRecord(rec.Namespace, rec.Dimensions)]
+		if !ok {
+			continue
+		}
+
+		acc.add(resource, strings.ToLower(rec.MetricName), rec)
+	}
+	return scanner.Err()
+}
+
+// dayAggregate accumulates every metric stream record falling on the same
+// day, so joinObject can collapse them into a single point instead of
+// dumping each record's per-period stats as its own fake "day".
+type dayAggregate struct {
+	day   time.Time
+	sum   float64
+	count float64
+	max   float64
+	min   float64
+}
+
+// metricAccumulator buckets incoming stream records by resource, metric
+// name, and day (derived from the record's own Timestamp) so a resource's
+// full set of Firehose objects can be merged into one daily series per
+// metric before being flushed onto ResourceSummary.Metrics - the same
+// shape ResourceMetricFetcher's GetMetricData path produces.
+type metricAccumulator map[*resources.ResourceSummary]map[string]map[int64]*dayAggregate
+
+func (acc metricAccumulator) add(resource *resources.ResourceSummary, metricName string, rec streamRecord) {
+	day := time.UnixMilli(rec.Timestamp).UTC().Truncate(24 * time.Hour)
+
+	byMetric, ok := acc[resource]
+	if !ok {
+		byMetric = map[string]map[int64]*dayAggregate{}
+		acc[resource] = byMetric
+	}
+	byDay, ok := byMetric[metricName]
+	if !ok {
+		byDay = map[int64]*dayAggregate{}
+		byMetric[metricName] = byDay
+	}
+	agg, ok := byDay[day.Unix()]
+	if !ok {
+		agg = &dayAggregate{day: day, max: rec.Value.Max, min: rec.Value.Min}
+		byDay[day.Unix()] = agg
+	}
+	agg.sum += rec.Value.Sum
+	agg.count += rec.Value.Count
+	if rec.Value.Max > agg.max {
+		agg.max = rec.Value.Max
+	}
+	if rec.Value.Min < agg.min {
+		agg.min = rec.Value.Min
+	}
+}
+
+// flush turns every resource's accumulated daily aggregates into one
+// MetricBlob per metric name, picking whichever stat (Sum, Average,
+// Maximum or Minimum) the resource's GetMetricTargets asks for, ordered
+// oldest-day-first with the matching Unix millisecond Timestamps.
+func (acc metricAccumulator) flush() {
+	for resource, byMetric := range acc {
+		targets := resource.Resource.GetMetricTargets(resource)
+		statFor := map[string]string{}
+		for statType, metricNames := range targets.Targets {
+			for _, name := range metricNames {
+				statFor[strings.ToLower(name)] = statType
+			}
+		}
+
+		for metricName, byDay := range byMetric {
+			days := make([]int64, 0, len(byDay))
+			for day := range byDay {
+				days = append(days, day)
+			}
+			sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+
+			blob := resources.MetricBlob{Name: metricName}
+			for _, day := range days {
+				agg := byDay[day]
+				var value float64
+				switch statFor[metricName] {
+				case "Maximum":
+					value = agg.max
+				case "Minimum":
+					value = agg.min
+				case "Average":
+					if agg.count > 0 {
+						value = agg.sum / agg.count
+					}
+				default: // "Sum", or unknown
+					value = agg.sum
+				}
+				blob.Values = append(blob.Values, value)
+				blob.Timestamps = append(blob.Timestamps, agg.day.UnixMilli())
+			}
+			resource.Metrics = append(resource.Metrics, blob)
+		}
+	}
+}
+
+// streamJoinKeyFromCW builds a stable lookup key out of a namespace and a
+// CloudWatch dimension set, as produced by Resource.GetMetricTargets.
+func streamJoinKeyFromCW(namespace string, dims []types.Dimension) string {
+	pairs := make([]string, 0, len(dims))
+	for _, d := range dims {
+		pairs = append(pairs, aws.ToString(d.Name)+"="+aws.ToString(d.Value))
+	}
+	return joinKey(namespace, pairs)
+}
+
+// streamJoinKeyFromRecord builds the same lookup key out of a metric stream
+// record's namespace and dimension map.
+func streamJoinKeyFromRecord(namespace string, dims map[string]string) string {
+	pairs := make([]string, 0, len(dims))
+	for name, value := range dims {
+		pairs = append(pairs, name+"="+value)
+	}
+	return joinKey(namespace, pairs)
+}
+
+func joinKey(namespace string, pairs []string) string {
+	sort.Strings(pairs)
+	return namespace + "|" + strings.Join(pairs, ",")
+}
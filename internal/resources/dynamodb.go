@@ -6,9 +6,16 @@ import (
 	cwTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/momentohq/aws-usage-analyzer/internal/stats"
+	"log/slog"
 	"strconv"
 )
 
+var (
+	describeTableCalls      = stats.NewCounter32("ddb.describe_table.calls")
+	describeTimeToLiveCalls = stats.NewCounter32("ddb.describe_time_to_live.calls")
+)
+
 var ddbTableMetricsToGet = map[string][]string{
 	"Sum": {
 		"ConsumedReadCapacityUnits",
@@ -30,6 +37,20 @@ var ddbGSIMetricsToGet = map[string][]string{
 
 type DynamoDb struct {
 	Client *dynamodb.Client
+	Logger *slog.Logger
+
+	// Streams, if set, folds the Stream/GlobalTableReplica resources it
+	// would otherwise discover via its own GetAll into this GetAll's
+	// results, reusing the DescribeTable response already fetched here
+	// instead of making DynamoDbStreams walk every table again.
+	Streams *DynamoDbStreams
+}
+
+func (ddb *DynamoDb) logger() *slog.Logger {
+	if ddb.Logger != nil {
+		return ddb.Logger
+	}
+	return slog.Default()
 }
 
 func (ddb *DynamoDb) GetMetricTargets(r *ResourceSummary) ResourceMetricTargets {
@@ -94,6 +115,7 @@ func (ddb *DynamoDb) GetAll() ([]*ResourceSummary, error) {
 	for _, table := range tables {
 
 		// Fetch info about table
+		describeTableCalls.Inc(1)
 		dRsp, err := ddb.Client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
 			TableName: aws.String(table),
 		})
@@ -101,6 +123,7 @@ func (ddb *DynamoDb) GetAll() ([]*ResourceSummary, error) {
 			return nil, err
 		}
 		// TODO this is very slow API maybe break into its own step
+		describeTimeToLiveCalls.Inc(1)
 		dTTLRsp, err := ddb.Client.DescribeTimeToLive(context.TODO(), &dynamodb.DescribeTimeToLiveInput{
 			TableName: aws.String(table),
 		})
@@ -173,6 +196,10 @@ func (ddb *DynamoDb) GetAll() ([]*ResourceSummary, error) {
 			Resource:       ddb,
 		})
 		returnList = append(returnList, gsiList...)
+
+		if ddb.Streams != nil {
+			returnList = append(returnList, ddb.Streams.resourcesForTable(table, dRsp)...)
+		}
 	}
 
 	return returnList, nil
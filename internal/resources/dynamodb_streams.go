@@ -0,0 +1,165 @@
+package resources
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/momentohq/aws-usage-analyzer/internal/stats"
+	"log/slog"
+)
+
+var describeStreamsTableCalls = stats.NewCounter32("ddb_streams.describe_table.calls")
+
+// ddbStreamMetricsToGet are the stream-level metrics DynamoDB publishes
+// under AWS/DynamoDB with a TableName + Operation dimension pair (the
+// Operation is always GetRecords, the only stream API CloudWatch reports
+// these against); querying with TableName alone returns no data points.
+var ddbStreamMetricsToGet = map[string][]string{
+	"Sum": {
+		"ReturnedRecordsCount",
+	},
+	"Average": {
+		"IteratorAgeMilliseconds",
+	},
+}
+
+const ddbStreamOperation = "GetRecords"
+
+var ddbGlobalTableReplicaMetricsToGet = map[string][]string{
+	"Average": {
+		"ReplicationLatency",
+		"PendingReplicationCount",
+	},
+}
+
+// DynamoDbStreams discovers per-table DynamoDB Streams and Global Table
+// replicas, which surface their own CloudWatch metrics (stream propagation
+// lag, cross-region replication lag) that the plain DynamoDb table/GSI
+// collector doesn't cover.
+type DynamoDbStreams struct {
+	Client *dynamodb.Client
+	Logger *slog.Logger
+}
+
+func (ds *DynamoDbStreams) logger() *slog.Logger {
+	if ds.Logger != nil {
+		return ds.Logger
+	}
+	return slog.Default()
+}
+
+func (ds *DynamoDbStreams) GetAll() ([]*ResourceSummary, error) {
+	var results []*ResourceSummary
+
+	listTableRsp, err := ds.Client.ListTables(context.TODO(), &dynamodb.ListTablesInput{
+		Limit: aws.Int32(100),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tables := listTableRsp.TableNames
+	lastTableSeen := listTableRsp.LastEvaluatedTableName
+	for lastTableSeen != nil {
+		listTableRsp, err := ds.Client.ListTables(context.TODO(), &dynamodb.ListTablesInput{
+			ExclusiveStartTableName: lastTableSeen,
+			Limit:                   aws.Int32(100),
+		})
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, listTableRsp.TableNames...)
+		lastTableSeen = listTableRsp.LastEvaluatedTableName
+	}
+
+	for _, table := range tables {
+		describeStreamsTableCalls.Inc(1)
+		dRsp, err := ds.Client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
+			TableName: aws.String(table),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, ds.resourcesForTable(table, dRsp)...)
+	}
+
+	return results, nil
+}
+
+// resourcesForTable builds the Stream/GlobalTableReplica ResourceSummary
+// entries for a single table's already-fetched DescribeTable response.
+// DynamoDb.GetAll calls this directly with the DescribeTableOutput it
+// fetched for its own table/GSI metadata, so the two collectors share one
+// ListTables+DescribeTable pass instead of DynamoDbStreams walking every
+// table in the account again on its own.
+func (ds *DynamoDbStreams) resourcesForTable(table string, dRsp *dynamodb.DescribeTableOutput) []*ResourceSummary {
+	var results []*ResourceSummary
+
+	if dRsp.Table.StreamSpecification != nil && dRsp.Table.StreamSpecification.StreamEnabled != nil && *dRsp.Table.StreamSpecification.StreamEnabled {
+		streamArn := ""
+		if dRsp.Table.LatestStreamArn != nil {
+			streamArn = *dRsp.Table.LatestStreamArn
+		}
+		results = append(results, &ResourceSummary{
+			ID:   table,
+			Type: AwsDynamoDbStream,
+			AdditionalData: map[string]string{
+				"stream_arn":       streamArn,
+				"stream_view_type": string(dRsp.Table.StreamSpecification.StreamViewType),
+			},
+			Resource: ds,
+		})
+	}
+
+	for _, replica := range dRsp.Table.Replicas {
+		region := *replica.RegionName
+		results = append(results, &ResourceSummary{
+			ID:   table + "/" + region,
+			Type: AwsDynamoDbGlobalTableReplica,
+			AdditionalData: map[string]string{
+				"table_name":     table,
+				"region":         region,
+				"replica_status": string(replica.ReplicaStatus),
+			},
+			Resource: ds,
+		})
+	}
+
+	return results
+}
+
+func (ds *DynamoDbStreams) GetMetricTargets(r *ResourceSummary) ResourceMetricTargets {
+	if r.Type == AwsDynamoDbGlobalTableReplica {
+		return ResourceMetricTargets{
+			Namespace: "AWS/DynamoDB",
+			Dimensions: []cwTypes.Dimension{
+				{
+					Name:  aws.String("TableName"),
+					Value: aws.String(r.AdditionalData["table_name"]),
+				},
+				{
+					Name:  aws.String("ReceivingRegion"),
+					Value: aws.String(r.AdditionalData["region"]),
+				},
+			},
+			Targets: ddbGlobalTableReplicaMetricsToGet,
+		}
+	}
+
+	return ResourceMetricTargets{
+		Namespace: "AWS/DynamoDB",
+		Dimensions: []cwTypes.Dimension{
+			{
+				Name:  aws.String("TableName"),
+				Value: aws.String(r.ID),
+			},
+			{
+				Name:  aws.String("Operation"),
+				Value: aws.String(ddbStreamOperation),
+			},
+		},
+		Targets: ddbStreamMetricsToGet,
+	}
+}
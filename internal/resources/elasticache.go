@@ -5,13 +5,26 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/aws/aws-sdk-go-v2/service/elasticache"
-	"log"
+	"github.com/momentohq/aws-usage-analyzer/internal/stats"
+	"log/slog"
 	"strconv"
-	"strings"
+)
+
+var (
+	describeCacheClustersCalls     = stats.NewCounter32("elasticache.describe_cache_clusters.calls")
+	describeReplicationGroupsCalls = stats.NewCounter32("elasticache.describe_replication_groups.calls")
 )
 
 type Elasticache struct {
 	Client *elasticache.Client
+	Logger *slog.Logger
+}
+
+func (ec *Elasticache) logger() *slog.Logger {
+	if ec.Logger != nil {
+		return ec.Logger
+	}
+	return slog.Default()
 }
 
 var cacheMetricsToGet = map[string][]string{
@@ -55,8 +68,48 @@ var cacheMetricsToGet = map[string][]string{
 	},
 }
 
+// replicationGroupClusterModeEnabled describes every replication group up
+// front so redis nodes can look up whether they're cluster-mode-enabled by
+// ReplicationGroupId, instead of guessing from the CacheClusterId suffix
+// (which isn't reliable across how a replication group was provisioned).
+func (ec *Elasticache) replicationGroupClusterModeEnabled() (map[string]bool, error) {
+	clusterModeByGroup := map[string]bool{}
+
+	describeReplicationGroupsCalls.Inc(1)
+	rsp, err := ec.Client.DescribeReplicationGroups(context.TODO(), &elasticache.DescribeReplicationGroupsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, g := range rsp.ReplicationGroups {
+			clusterModeByGroup[*g.ReplicationGroupId] = g.ClusterEnabled != nil && *g.ClusterEnabled
+		}
+
+		if rsp.Marker == nil {
+			break
+		}
+		describeReplicationGroupsCalls.Inc(1)
+		rsp, err = ec.Client.DescribeReplicationGroups(context.TODO(), &elasticache.DescribeReplicationGroupsInput{
+			Marker: rsp.Marker,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return clusterModeByGroup, nil
+}
+
 func (ec *Elasticache) GetAll() ([]*ResourceSummary, error) {
 	var results []*ResourceSummary
+
+	clusterModeByGroup, err := ec.replicationGroupClusterModeEnabled()
+	if err != nil {
+		return nil, err
+	}
+
+	describeCacheClustersCalls.Inc(1)
 	rsp, err := ec.Client.DescribeCacheClusters(context.TODO(), &elasticache.DescribeCacheClustersInput{
 		ShowCacheNodeInfo: aws.Bool(true),
 	})
@@ -69,20 +122,12 @@ func (ec *Elasticache) GetAll() ([]*ResourceSummary, error) {
 			// Elasticache has different API responses depending on engine type need handle both :/
 			switch *c.Engine {
 			case "redis":
-				// Determine if redis node is in cluster mode or not
+				// Determine if redis node is in cluster mode or not via its
+				// replication group, rather than guessing from the
+				// CacheClusterId suffix.
 				clusterModeEnabled := false
 				if c.ReplicationGroupId != nil {
-					if len(
-						strings.Split(
-							strings.TrimPrefix(*c.CacheClusterId, *c.ReplicationGroupId+"-"),
-							"-",
-						),
-						// If is in redis cluster mode will have suffix like 002-002 vs just 002
-						// This seems to be the best way to determine this from this API right
-						// now :(
-					) == 2 {
-						clusterModeEnabled = true
-					}
+					clusterModeEnabled = clusterModeByGroup[*c.ReplicationGroupId]
 				}
 
 				// Elasticache does not set ReplicationGroupId consistently on non replicated
@@ -124,19 +169,16 @@ func (ec *Elasticache) GetAll() ([]*ResourceSummary, error) {
 		}
 
 		// Paginate till we got all cache clusters
-		if rsp.Marker != nil {
-			rsp, err = ec.Client.DescribeCacheClusters(context.TODO(), &elasticache.DescribeCacheClustersInput{
-				Marker: rsp.Marker,
-			})
-			if err != nil {
-				log.Printf("error grabbing cache nodes err=%+v\n", err)
-			}
-		} else {
+		if rsp.Marker == nil {
 			break
 		}
-	}
-	if err != nil {
-		return nil, err
+		describeCacheClustersCalls.Inc(1)
+		rsp, err = ec.Client.DescribeCacheClusters(context.TODO(), &elasticache.DescribeCacheClustersInput{
+			Marker: rsp.Marker,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 	return results, nil
 }
@@ -0,0 +1,89 @@
+package resources
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/momentohq/aws-usage-analyzer/internal/stats"
+	"log/slog"
+)
+
+var describeServerlessCachesCalls = stats.NewCounter32("elasticache.describe_serverless_caches.calls")
+
+var serverlessCacheMetricsToGet = map[string][]string{
+	"Sum": {
+		"NetworkBytesIn",
+		"NetworkBytesOut",
+	},
+	"Maximum": {
+		"BytesUsedForCache",
+		"ElastiCacheProcessingUnits",
+	},
+}
+
+// ElasticacheServerless discovers ElastiCache Serverless caches, which are
+// billed and monitored per-cache (ElastiCacheProcessingUnits) rather than
+// per-node like the classic Elasticache node-based clusters.
+type ElasticacheServerless struct {
+	Client *elasticache.Client
+	Logger *slog.Logger
+}
+
+func (ec *ElasticacheServerless) logger() *slog.Logger {
+	if ec.Logger != nil {
+		return ec.Logger
+	}
+	return slog.Default()
+}
+
+func (ec *ElasticacheServerless) GetAll() ([]*ResourceSummary, error) {
+	var results []*ResourceSummary
+
+	describeServerlessCachesCalls.Inc(1)
+	rsp, err := ec.Client.DescribeServerlessCaches(context.TODO(), &elasticache.DescribeServerlessCachesInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, c := range rsp.ServerlessCaches {
+			results = append(results, &ResourceSummary{
+				ID:   *c.ServerlessCacheName,
+				Type: AwsElasticacheServerless,
+				AdditionalData: map[string]string{
+					"engine": *c.Engine,
+					"status": *c.Status,
+				},
+				Resource: ec,
+			})
+		}
+
+		if rsp.NextToken == nil {
+			break
+		}
+		describeServerlessCachesCalls.Inc(1)
+		rsp, err = ec.Client.DescribeServerlessCaches(context.TODO(), &elasticache.DescribeServerlessCachesInput{
+			NextToken: rsp.NextToken,
+		})
+		if err != nil {
+			ec.logger().Error("error grabbing serverless caches", "err", err)
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (ec *ElasticacheServerless) GetMetricTargets(r *ResourceSummary) ResourceMetricTargets {
+	return ResourceMetricTargets{
+		Namespace: "AWS/ElastiCache",
+		Dimensions: []types.Dimension{
+			{
+				Name:  aws.String("CacheClusterId"),
+				Value: aws.String(r.ID),
+			},
+		},
+		Targets: serverlessCacheMetricsToGet,
+	}
+}
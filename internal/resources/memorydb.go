@@ -0,0 +1,108 @@
+package resources
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/memorydb"
+	"github.com/momentohq/aws-usage-analyzer/internal/stats"
+	"log/slog"
+)
+
+var describeMemoryDbClustersCalls = stats.NewCounter32("memorydb.describe_clusters.calls")
+
+var memoryDbMetricsToGet = map[string][]string{
+	"Sum": {
+		"NetworkBytesIn",
+		"NetworkBytesOut",
+	},
+	"Maximum": {
+		"CurrConnections",
+		"NewConnections",
+
+		"EngineCPUUtilization",
+		"CPUUtilization",
+		"FreeableMemory",
+
+		"BytesUsedForCache",
+		"CurrItems",
+		"Evictions",
+	},
+}
+
+type MemoryDb struct {
+	Client *memorydb.Client
+	Logger *slog.Logger
+}
+
+func (m *MemoryDb) logger() *slog.Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	return slog.Default()
+}
+
+func (m *MemoryDb) GetAll() ([]*ResourceSummary, error) {
+	var results []*ResourceSummary
+
+	describeMemoryDbClustersCalls.Inc(1)
+	rsp, err := m.Client.DescribeClusters(context.TODO(), &memorydb.DescribeClustersInput{
+		ShowShardDetails: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, c := range rsp.Clusters {
+			for _, shard := range c.Shards {
+				for _, node := range shard.Nodes {
+					results = append(results, &ResourceSummary{
+						ID:   *node.Name,
+						Type: AwsMemoryDbNode,
+						AdditionalData: map[string]string{
+							"cluster_name": *c.Name,
+							"shard_name":   *shard.Name,
+							"node_type":    *c.NodeType,
+							"engine":       "redis",
+							"preferred_az": *node.AvailabilityZone,
+						},
+						Resource: m,
+					})
+				}
+			}
+		}
+
+		if rsp.NextToken == nil {
+			break
+		}
+		describeMemoryDbClustersCalls.Inc(1)
+		rsp, err = m.Client.DescribeClusters(context.TODO(), &memorydb.DescribeClustersInput{
+			ShowShardDetails: aws.Bool(true),
+			NextToken:        rsp.NextToken,
+		})
+		if err != nil {
+			m.logger().Error("error grabbing memorydb clusters", "err", err)
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (m *MemoryDb) GetMetricTargets(r *ResourceSummary) ResourceMetricTargets {
+	return ResourceMetricTargets{
+		Namespace: "AWS/MemoryDB",
+		Dimensions: []types.Dimension{
+			{
+				Name:  aws.String("ClusterName"),
+				Value: aws.String(r.AdditionalData["cluster_name"]),
+			},
+			{
+				Name:  aws.String("NodeName"),
+				Value: aws.String(r.ID),
+			},
+		},
+		Targets: memoryDbMetricsToGet,
+	}
+}
@@ -5,6 +5,13 @@ import "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 type MetricBlob struct {
 	Name   string    `json:"name"`
 	Values []float64 `json:"values"`
+	// Timestamps is the Unix millisecond timestamp each Values[i] was
+	// observed at. It is parallel to Values: Timestamps[i] is the real
+	// timestamp CloudWatch (or a metric stream record) reported for
+	// Values[i], since GetMetricData returns points newest-first and omits
+	// periods with no data, so a value's index alone can't be turned back
+	// into a day.
+	Timestamps []int64 `json:"timestamps"`
 }
 
 type ResourceType string
@@ -14,13 +21,29 @@ const (
 	AwsElasticacheMemcachedNode ResourceType = "AWS::Elasticache::MemcachedNode"
 	AwsDynamoDbTable                         = "AWS::DynamoDB::Table"
 	AwsDynamoDbGsi                           = "AWS::DynamoDB::GSI"
+
+	AwsElasticacheServerless      ResourceType = "AWS::Elasticache::ServerlessCache"
+	AwsMemoryDbNode               ResourceType = "AWS::MemoryDB::Node"
+	AwsDynamoDbStream             ResourceType = "AWS::DynamoDB::Stream"
+	AwsDynamoDbGlobalTableReplica ResourceType = "AWS::DynamoDB::GlobalTableReplica"
 )
 
+// CollectionError records a single failed collection call against a
+// resource (e.g. a throttled GetMetricData), including the upstream
+// request ID when the SDK error exposes one, so failures are visible in
+// results.csv/errors.json instead of only in the logs.
+type CollectionError struct {
+	Stage     string `json:"stage"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
 type ResourceSummary struct {
 	ID             string            `json:"id"`
 	Type           ResourceType      `json:"type"`
 	AdditionalData map[string]string `json:"additional_data"`
 	Metrics        []MetricBlob      `json:"metrics"`
+	Errors         []CollectionError `json:"errors,omitempty"`
 	Resource       Resource
 }
 
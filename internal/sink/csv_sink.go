@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+
+	"github.com/momentohq/aws-usage-analyzer/internal/resources"
+)
+
+// CSVSink writes the original results.csv format: one row per resource
+// with AdditionalData/Metrics embedded as JSON, plus a companion
+// errors.json for any per-resource collection failures.
+type CSVSink struct {
+	// Path defaults to "./results.csv".
+	Path string
+	// ErrorsPath defaults to "./errors.json".
+	ErrorsPath string
+}
+
+func (s *CSVSink) path() string {
+	if s.Path != "" {
+		return s.Path
+	}
+	return "./results.csv"
+}
+
+func (s *CSVSink) errorsPath() string {
+	if s.ErrorsPath != "" {
+		return s.ErrorsPath
+	}
+	return "./errors.json"
+}
+
+func (s *CSVSink) Write(_ context.Context, results []*resources.ResourceSummary) error {
+	f, err := os.Create(s.path())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+
+	data := [][]string{
+		{"ResourceId", "Type", "AdditionalData", "Metrics", "Errors"},
+	}
+	for _, r := range results {
+		metrics, err := json.Marshal(r.Metrics)
+		if err != nil {
+			return err
+		}
+		additionalData, err := json.Marshal(r.AdditionalData)
+		if err != nil {
+			return err
+		}
+		collectionErrors, err := json.Marshal(r.Errors)
+		if err != nil {
+			return err
+		}
+		data = append(data, []string{
+			r.ID,
+			string(r.Type),
+			string(additionalData),
+			string(metrics),
+			string(collectionErrors),
+		})
+	}
+
+	if err := writer.WriteAll(data); err != nil {
+		return err
+	}
+
+	return s.writeErrors(results)
+}
+
+// resourceErrors is one resource's collection failures, as written to
+// errors.json alongside results.csv.
+type resourceErrors struct {
+	ResourceID string                      `json:"resource_id"`
+	Type       resources.ResourceType      `json:"type"`
+	Errors     []resources.CollectionError `json:"errors"`
+}
+
+func (s *CSVSink) writeErrors(results []*resources.ResourceSummary) error {
+	var allErrors []resourceErrors
+	for _, r := range results {
+		if len(r.Errors) == 0 {
+			continue
+		}
+		allErrors = append(allErrors, resourceErrors{ResourceID: r.ID, Type: r.Type, Errors: r.Errors})
+	}
+
+	f, err := os.Create(s.errorsPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(allErrors)
+}
@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/momentohq/aws-usage-analyzer/internal/resources"
+)
+
+// NDJSONSink writes one newline-delimited JSON MetricRow per (resource,
+// day, metric), which is easier for downstream tools to stream or load
+// into a warehouse than the CSV format's embedded JSON blobs.
+type NDJSONSink struct {
+	// Path defaults to "./results.ndjson".
+	Path string
+}
+
+func (s *NDJSONSink) path() string {
+	if s.Path != "" {
+		return s.Path
+	}
+	return "./results.ndjson"
+}
+
+func (s *NDJSONSink) Write(_ context.Context, results []*resources.ResourceSummary) error {
+	f, err := os.Create(s.path())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, row := range FlattenMetricRows(results, time.Now()) {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
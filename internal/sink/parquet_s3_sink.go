@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/momentohq/aws-usage-analyzer/internal/resources"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ParquetS3Sink writes one row per (resource, day, metric) to a Parquet
+// file uploaded to S3, so the collected time series is directly queryable
+// via Athena instead of requiring results.csv to be shipped and unpacked
+// by hand.
+type ParquetS3Sink struct {
+	S3 *s3.Client
+	// URI is the destination object, e.g. "s3://my-bucket/usage/run.parquet".
+	URI string
+}
+
+func (s *ParquetS3Sink) Write(ctx context.Context, results []*resources.ResourceSummary) error {
+	bucket, key, err := parseS3URI(s.URI)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "aws-usage-analyzer-*.parquet")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	fw, err := local.NewLocalFileWriter(tmpPath)
+	if err != nil {
+		return err
+	}
+	pw, err := writer.NewParquetWriter(fw, new(MetricRow), 4)
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for _, row := range FlattenMetricRows(results, time.Now()) {
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.S3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func parseS3URI(uri string) (bucket, key string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" {
+		return "", "", fmt.Errorf("parquet s3 sink: uri %q is not an s3:// uri", uri)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
@@ -0,0 +1,98 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/momentohq/aws-usage-analyzer/internal/resources"
+
+	"github.com/golang/snappy"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// RemoteWriteSink pushes every (resource, day, metric) observation to a
+// Prometheus remote_write endpoint, so the analyzer can run as a periodic
+// scrape job instead of needing someone to ship results.csv to Momento.
+//
+// A default Prometheus head only accepts samples within its out-of-order
+// window (a few hours), so backfilling 30 days of history this way will be
+// rejected by most remote_write receivers unless the endpoint is configured
+// (or is a backend like Mimir/Cortex) to accept out-of-bounds historical
+// samples.
+type RemoteWriteSink struct {
+	Client   *http.Client
+	Endpoint string
+}
+
+func (s *RemoteWriteSink) httpClient() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s *RemoteWriteSink) Write(ctx context.Context, results []*resources.ResourceSummary) error {
+	rows := FlattenMetricRows(results, time.Now())
+	if len(rows) == 0 {
+		return nil
+	}
+
+	req := &prompb.WriteRequest{
+		Timeseries: make([]prompb.TimeSeries, 0, len(rows)),
+	}
+	for _, row := range rows {
+		day, err := time.Parse("2006-01-02", row.Day)
+		if err != nil {
+			return fmt.Errorf("parsing metric row day %q: %w", row.Day, err)
+		}
+
+		labels := []prompb.Label{
+			{Name: "__name__", Value: "aws_usage_" + row.MetricName},
+			{Name: "resource_id", Value: row.ResourceID},
+			{Name: "resource_type", Value: row.ResourceType},
+		}
+		for k, v := range row.AdditionalData {
+			labels = append(labels, prompb.Label{Name: k, Value: v})
+		}
+		// The remote_write spec requires labels sorted lexicographically by
+		// name; Prometheus/Cortex/Mimir reject a series that isn't.
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels: labels,
+			Samples: []prompb.Sample{
+				{Value: row.Value, Timestamp: day.UnixMilli()},
+			},
+		})
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	compressed := snappy.Encode(nil, body)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	rsp, err := s.httpClient().Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode/100 != 2 {
+		return fmt.Errorf("remote_write sink: endpoint %s returned status %d", s.Endpoint, rsp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Package sink defines the pluggable output destinations results can be
+// written to once collection finishes: a local CSV file (the original
+// format), newline-delimited JSON, Parquet on S3 for Athena, or a direct
+// push to a Prometheus remote_write endpoint.
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/momentohq/aws-usage-analyzer/internal/resources"
+)
+
+// Sink writes a finished collection run's results somewhere.
+type Sink interface {
+	Write(ctx context.Context, results []*resources.ResourceSummary) error
+}
+
+// MetricRow is one (resource, day, metric) observation, flattened out of a
+// ResourceSummary's embedded AdditionalData/Metrics JSON blobs so the
+// NDJSON and Parquet sinks can emit native columns instead of a blob
+// downstream tools have to re-parse.
+type MetricRow struct {
+	ResourceID     string            `json:"resource_id" parquet:"name=resource_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ResourceType   string            `json:"resource_type" parquet:"name=resource_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Day            string            `json:"day" parquet:"name=day, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MetricName     string            `json:"metric_name" parquet:"name=metric_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Value          float64           `json:"value" parquet:"name=value, type=DOUBLE"`
+	AdditionalData map[string]string `json:"additional_data" parquet:"name=additional_data, type=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+}
+
+// FlattenMetricRows expands every resource's Metrics (one array of values
+// per metric name, paired with the Unix millisecond timestamp each value
+// was observed at) into one MetricRow per (resource, day, metric).
+// Resources without any metric points (e.g. a failed collection) are
+// skipped here — their failures belong in errors.json, not the metric
+// table. A value with no matching Timestamps entry is skipped too, since
+// there's no day to anchor it on.
+func FlattenMetricRows(results []*resources.ResourceSummary, _ time.Time) []MetricRow {
+	var rows []MetricRow
+	for _, r := range results {
+		for _, metric := range r.Metrics {
+			for i, value := range metric.Values {
+				if i >= len(metric.Timestamps) {
+					continue
+				}
+				day := time.UnixMilli(metric.Timestamps[i]).UTC()
+				rows = append(rows, MetricRow{
+					ResourceID:     r.ID,
+					ResourceType:   string(r.Type),
+					Day:            day.Format("2006-01-02"),
+					MetricName:     metric.Name,
+					Value:          value,
+					AdditionalData: r.AdditionalData,
+				})
+			}
+		}
+	}
+	return rows
+}
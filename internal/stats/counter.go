@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Counter32 is a monotonically increasing counter backed by an atomic
+// uint32, for things like "number of DescribeTable calls made".
+type Counter32 struct {
+	metricName string
+	value      uint32
+}
+
+// NewCounter32 creates and registers a Counter32 under name. name should be
+// dotted, e.g. "ddb.describe_table.calls".
+func NewCounter32(name string) *Counter32 {
+	c := &Counter32{metricName: name}
+	register(c)
+	return c
+}
+
+// Inc increments the counter by val.
+func (c *Counter32) Inc(val uint32) {
+	atomic.AddUint32(&c.value, val)
+}
+
+// Value returns the current counter value.
+func (c *Counter32) Value() uint32 {
+	return atomic.LoadUint32(&c.value)
+}
+
+func (c *Counter32) name() string { return c.metricName }
+
+func (c *Counter32) writeText(sb *strings.Builder) {
+	metric := promName(c.metricName)
+	fmt.Fprintf(sb, "# TYPE %s counter\n%s %d\n", metric, metric, c.Value())
+}
@@ -0,0 +1,67 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBucketBoundsMs are the upper bounds, in milliseconds, of the 32
+// buckets a LatencyHistogram15s32 sorts observations into. They are spaced
+// exponentially so that both a fast DescribeTable call (single digit ms)
+// and a slow throttled GetMetricData call (tens of seconds) land in a
+// meaningful bucket.
+var latencyBucketBoundsMs = func() [32]float64 {
+	var bounds [32]float64
+	ms := 1.0
+	for i := range bounds {
+		bounds[i] = ms
+		ms *= 1.5
+	}
+	return bounds
+}()
+
+// LatencyHistogram15s32 is a 32-bucket latency histogram, named for the
+// ~15s operations (a single GetMetricData call, a resource's full fetch) it
+// is intended to time. Buckets are cumulative counts, same as a Prometheus
+// histogram, so Report() can emit it directly in that format.
+type LatencyHistogram15s32 struct {
+	metricName string
+	buckets    [32]uint64
+	count      uint64
+	sumMs      uint64
+}
+
+// NewLatencyHistogram15s32 creates and registers a LatencyHistogram15s32
+// under name.
+func NewLatencyHistogram15s32(name string) *LatencyHistogram15s32 {
+	h := &LatencyHistogram15s32{metricName: name}
+	register(h)
+	return h
+}
+
+// Value records a single observed duration.
+func (h *LatencyHistogram15s32) Value(d time.Duration) {
+	ms := float64(d.Milliseconds())
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.sumMs, uint64(ms))
+	for i, bound := range latencyBucketBoundsMs {
+		if ms <= bound {
+			atomic.AddUint64(&h.buckets[i], 1)
+		}
+	}
+}
+
+func (h *LatencyHistogram15s32) name() string { return h.metricName }
+
+func (h *LatencyHistogram15s32) writeText(sb *strings.Builder) {
+	metric := promName(h.metricName)
+	fmt.Fprintf(sb, "# TYPE %s histogram\n", metric)
+	for i, bound := range latencyBucketBoundsMs {
+		fmt.Fprintf(sb, "%s_bucket{le=\"%g\"} %d\n", metric, bound, atomic.LoadUint64(&h.buckets[i]))
+	}
+	fmt.Fprintf(sb, "%s_bucket{le=\"+Inf\"} %d\n", metric, atomic.LoadUint64(&h.count))
+	fmt.Fprintf(sb, "%s_sum %d\n", metric, atomic.LoadUint64(&h.sumMs))
+	fmt.Fprintf(sb, "%s_count %d\n", metric, atomic.LoadUint64(&h.count))
+}
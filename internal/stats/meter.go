@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Meter32 tracks the most recently observed value of something that moves
+// up and down, such as "number of in-flight GetMetricData calls", as
+// opposed to Counter32 which only ever increases.
+type Meter32 struct {
+	metricName string
+	value      uint32
+}
+
+// NewMeter32 creates and registers a Meter32 under name.
+func NewMeter32(name string) *Meter32 {
+	m := &Meter32{metricName: name}
+	register(m)
+	return m
+}
+
+// Set records the current value of the meter.
+func (m *Meter32) Set(val uint32) {
+	atomic.StoreUint32(&m.value, val)
+}
+
+// Value returns the last value recorded via Set.
+func (m *Meter32) Value() uint32 {
+	return atomic.LoadUint32(&m.value)
+}
+
+func (m *Meter32) name() string { return m.metricName }
+
+func (m *Meter32) writeText(sb *strings.Builder) {
+	metric := promName(m.metricName)
+	fmt.Fprintf(sb, "# TYPE %s gauge\n%s %d\n", metric, metric, m.Value())
+}
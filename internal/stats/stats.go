@@ -0,0 +1,60 @@
+// Package stats provides lightweight, dependency-free self-telemetry for the
+// analyzer's collector hot paths: counters, meters and latency histograms
+// that can be scraped over HTTP in Prometheus text format. Naming follows
+// the api.request_handle / api.get_target instrumentation pattern used by
+// metrictank, so operators pointed at this from other Momento tooling will
+// recognize the shape.
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metric is implemented by every stat type registered with the default
+// registry so Report can render them without a type switch per kind.
+type metric interface {
+	name() string
+	writeText(sb *strings.Builder)
+}
+
+var (
+	mu          sync.Mutex
+	allMetrics  []metric
+	metricNames = map[string]struct{}{}
+)
+
+func register(m metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := metricNames[m.name()]; exists {
+		panic(fmt.Sprintf("stats: metric %q already registered", m.name()))
+	}
+	metricNames[m.name()] = struct{}{}
+	allMetrics = append(allMetrics, m)
+}
+
+// promName converts a dotted stat name (e.g. "ddb.describe_table.calls")
+// into a Prometheus-safe metric name (e.g. "ddb_describe_table_calls").
+func promName(name string) string {
+	return strings.ReplaceAll(name, ".", "_")
+}
+
+// Report renders every registered metric in Prometheus text exposition
+// format, suitable for serving directly from an HTTP handler.
+func Report() string {
+	mu.Lock()
+	metricsCopy := make([]metric, len(allMetrics))
+	copy(metricsCopy, allMetrics)
+	mu.Unlock()
+
+	sort.Slice(metricsCopy, func(i, j int) bool { return metricsCopy[i].name() < metricsCopy[j].name() })
+
+	var sb strings.Builder
+	for _, m := range metricsCopy {
+		m.writeText(&sb)
+	}
+	return sb.String()
+}
@@ -2,19 +2,39 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/momentohq/aws-usage-analyzer/internal/logging"
+	"github.com/momentohq/aws-usage-analyzer/internal/metrics"
 	"github.com/momentohq/ec-usage-analyzer/internal/handlers"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/memorydb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 func main() {
+	source := flag.String("source", handlers.SourceAPI, "metric collection backend to use: api or stream")
+	streamBucket := flag.String("stream-bucket", "", "S3 bucket that CloudWatch Metric Streams are delivered to (required when --source=stream)")
+	streamPrefix := flag.String("stream-prefix", "", "S3 key prefix to read metric stream objects from")
+	statsAddr := flag.String("stats-addr", "", "if set, serve Prometheus-format self-telemetry at http://<addr>/metrics")
+	maxConcurrency := flag.Int("max-concurrency", 0, "number of GetMetricData batches to fetch concurrently (default: "+fmt.Sprint(metrics.DefaultMaxConcurrency)+")")
+	rateLimitTPS := flag.Int("rate-limit-tps", 0, "GetMetricData calls per second to allow (default: "+fmt.Sprint(metrics.DefaultRateLimitTPS)+")")
+	logFormat := flag.String("log-format", "text", "log output format: text or json")
+	output := flag.String("output", handlers.OutputCSV, "comma-separated list of output sinks to write results to: csv, ndjson, parquet-s3, remote-write")
+	parquetS3URI := flag.String("parquet-s3-uri", "", "destination s3://bucket/key for the parquet-s3 output sink")
+	remoteWriteURL := flag.String("remote-write-url", "", "Prometheus remote_write endpoint for the remote-write output sink")
+	flag.Parse()
+
+	logger := logging.New(*logFormat)
 
 	cfg, err := config.LoadDefaultConfig(
 		context.TODO(),
@@ -32,17 +52,30 @@ func main() {
 		}),
 	)
 	if err != nil {
-		fmt.Println("error:", err)
+		logger.Error("error loading AWS config", "err", err)
 		os.Exit(1)
 	}
 
 	h := &handlers.Handler{
-		Cw: cloudwatch.NewFromConfig(cfg),
-		Ec: elasticache.NewFromConfig(cfg),
+		Cw:             cloudwatch.NewFromConfig(cfg),
+		Ec:             elasticache.NewFromConfig(cfg),
+		DDB:            dynamodb.NewFromConfig(cfg),
+		MemoryDb:       memorydb.NewFromConfig(cfg),
+		S3:             s3.NewFromConfig(cfg),
+		Source:         *source,
+		StreamBucket:   *streamBucket,
+		StreamPrefix:   *streamPrefix,
+		StatsAddr:      *statsAddr,
+		MaxConcurrency: *maxConcurrency,
+		RateLimitTPS:   *rateLimitTPS,
+		Logger:         logger,
+		Outputs:        strings.Split(*output, ","),
+		ParquetS3URI:   *parquetS3URI,
+		RemoteWriteURL: *remoteWriteURL,
 	}
-	err = h.Handle()
-	if err != nil {
-		panic(err)
+	if err := h.Handle(); err != nil {
+		logger.Error("collection failed", "err", err)
+		os.Exit(1)
 	}
 }
 